@@ -0,0 +1,193 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+// Allocator provides pooled storage for the objects this package allocates
+// most often while comparing or merging large objects: structValues,
+// structMaps, structLists, the []Field slices backing a structMap's Items,
+// and the map[string]*Field index a structMap builds lazily. Reusing these
+// across many Less/Equals calls (e.g. while diffing every field of every
+// managed object in an apiserver) avoids repeatedly churning the garbage
+// collector.
+//
+// An Allocator is not safe for concurrent use; callers that want to share
+// one across goroutines should give each goroutine its own.
+type Allocator struct {
+	noPool bool
+
+	valueFreelist  []*structValue
+	mapFreelist    []*structMap
+	listFreelist   []*structList
+	fieldsFreelist [][]Field
+	indexFreelist  []map[string]*Field
+}
+
+// NewFreelistAllocator returns an Allocator that recycles objects passed to
+// Free for reuse by later Alloc* calls.
+func NewFreelistAllocator() *Allocator {
+	return &Allocator{}
+}
+
+// SimpleAllocator is a ready-to-use Allocator that never pools: every
+// Alloc* call allocates fresh, and Free is a no-op. It is the default used
+// by this package's non-allocator-taking scalar constructors (StringValue,
+// IntValue, FloatValue, BooleanValue, NullValue), so existing callers keep
+// their original, straightforward allocation behavior. NewMapValue and
+// NewListValue allocate directly and use no Allocator.
+var SimpleAllocator = &Allocator{noPool: true}
+
+// AllocValue returns a Value backed by a *structValue, either recycled
+// from the pool or freshly allocated, with all fields zeroed.
+func (a *Allocator) AllocValue() Value {
+	return a.allocStructValue()
+}
+
+func (a *Allocator) allocStructValue() *structValue {
+	if n := len(a.valueFreelist); n > 0 {
+		v := a.valueFreelist[n-1]
+		a.valueFreelist = a.valueFreelist[:n-1]
+		*v = structValue{}
+		return v
+	}
+	return &structValue{}
+}
+
+// AllocMap returns a Value backed by a freshly-empty *structMap, either
+// recycled from the pool or freshly allocated.
+func (a *Allocator) AllocMap() Value {
+	m := a.allocStructMap()
+	return &structValue{MapValue: m}
+}
+
+func (a *Allocator) allocStructMap() *structMap {
+	if n := len(a.mapFreelist); n > 0 {
+		m := a.mapFreelist[n-1]
+		a.mapFreelist = a.mapFreelist[:n-1]
+		// Recycle m.index itself rather than discarding it here: Free
+		// never sees it once m is overwritten below, so without this it
+		// would never make it back into indexFreelist.
+		m.freeIndex()
+		*m = structMap{allocator: a}
+		return m
+	}
+	return &structMap{allocator: a}
+}
+
+// AllocList returns a Value backed by a freshly-empty *structList, either
+// recycled from the pool or freshly allocated.
+func (a *Allocator) AllocList() Value {
+	if n := len(a.listFreelist); n > 0 {
+		l := a.listFreelist[n-1]
+		a.listFreelist = a.listFreelist[:n-1]
+		*l = structList{}
+		return &structValue{ListValue: l}
+	}
+	return &structValue{ListValue: &structList{}}
+}
+
+// AllocFields returns a []Field with at least capacity n, either recycled
+// from the pool or freshly allocated.
+func (a *Allocator) AllocFields(n int) []Field {
+	for i := len(a.fieldsFreelist) - 1; i >= 0; i-- {
+		if cap(a.fieldsFreelist[i]) >= n {
+			fs := a.fieldsFreelist[i][:0]
+			a.fieldsFreelist = append(a.fieldsFreelist[:i], a.fieldsFreelist[i+1:]...)
+			return fs
+		}
+	}
+	return make([]Field, 0, n)
+}
+
+func (a *Allocator) allocIndex() map[string]*Field {
+	if !a.noPool {
+		if n := len(a.indexFreelist); n > 0 {
+			idx := a.indexFreelist[n-1]
+			a.indexFreelist = a.indexFreelist[:n-1]
+			return idx
+		}
+	}
+	return map[string]*Field{}
+}
+
+// Free returns v's backing object to the pool so a later Alloc* call can
+// reuse it. Free is a no-op for the SimpleAllocator, and for any value not
+// owned by this Allocator (e.g. a reflect- or unstructured-backed Value).
+// Callers must not use v again after calling Free on it.
+func (a *Allocator) Free(v interface{}) {
+	if a.noPool {
+		return
+	}
+	switch t := v.(type) {
+	case *structValue:
+		a.valueFreelist = append(a.valueFreelist, t)
+	case *structMap:
+		a.mapFreelist = append(a.mapFreelist, t)
+	case *structList:
+		a.listFreelist = append(a.listFreelist, t)
+	case []Field:
+		a.fieldsFreelist = append(a.fieldsFreelist, t)
+	case map[string]*Field:
+		for k := range t {
+			delete(t, k)
+		}
+		a.indexFreelist = append(a.indexFreelist, t)
+	}
+}
+
+// StringValue returns s as a scalar string Value, using a as the backing
+// allocator.
+func (a *Allocator) StringValue(s string) Value {
+	v := a.allocStructValue()
+	s2 := String(s)
+	v.StringValue = &s2
+	return v
+}
+
+// IntValue returns i as a scalar numeric (integer) Value, using a as the
+// backing allocator.
+func (a *Allocator) IntValue(i int) Value {
+	v := a.allocStructValue()
+	i2 := Int(i)
+	v.IntValue = &i2
+	return v
+}
+
+// FloatValue returns f as a scalar numeric (float) Value, using a as the
+// backing allocator.
+func (a *Allocator) FloatValue(f float64) Value {
+	v := a.allocStructValue()
+	f2 := Float(f)
+	v.FloatValue = &f2
+	return v
+}
+
+// BooleanValue returns b as a scalar boolean Value, using a as the backing
+// allocator.
+func (a *Allocator) BooleanValue(b bool) Value {
+	v := a.allocStructValue()
+	b2 := Boolean(b)
+	v.BooleanValue = &b2
+	return v
+}
+
+// NullValue returns an explicit null Value, using a as the backing
+// allocator.
+func (a *Allocator) NullValue() Value {
+	v := a.allocStructValue()
+	v.Null = true
+	return v
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "testing"
+
+func TestAllocatorRecyclesValue(t *testing.T) {
+	a := NewFreelistAllocator()
+	v := a.AllocValue()
+	sv := v.(*structValue)
+	a.Free(sv)
+
+	v2 := a.AllocValue()
+	if v2.(*structValue) != sv {
+		t.Fatal("AllocValue did not reuse the freed *structValue")
+	}
+}
+
+func TestAllocatorRecyclesMap(t *testing.T) {
+	a := NewFreelistAllocator()
+	v := a.AllocMap()
+	sm := v.(*structValue).MapValue
+	sm.Set("k", StringValue("v"))
+	a.Free(sm)
+
+	v2 := a.AllocMap()
+	sm2 := v2.(*structValue).MapValue
+	if sm2 != sm {
+		t.Fatal("AllocMap did not reuse the freed *structMap")
+	}
+	if sm2.Length() != 0 {
+		t.Fatalf("recycled map was not reset, Length() = %d", sm2.Length())
+	}
+}
+
+func TestAllocatorRecyclesMapIndex(t *testing.T) {
+	a := NewFreelistAllocator()
+	v := a.AllocMap()
+	sm := v.(*structValue).MapValue
+	// Force the lazy index to be built.
+	sm.Set("k", StringValue("v"))
+	if _, ok := sm.Get("k"); !ok {
+		t.Fatal("Get did not find the field that was just Set")
+	}
+	if sm.index == nil {
+		t.Fatal("Get did not build the lazy index")
+	}
+	a.Free(sm)
+
+	// allocStructMap only frees the outgoing index once the *structMap
+	// itself is actually recycled, not on Free alone.
+	v2 := a.AllocMap()
+	sm2 := v2.(*structValue).MapValue
+	if sm2 != sm {
+		t.Fatal("AllocMap did not reuse the freed *structMap")
+	}
+	if sm2.index != nil {
+		t.Fatal("a recycled map must start with a nil index")
+	}
+	if len(a.indexFreelist) != 1 {
+		t.Fatalf("recycling the map did not return its old index to indexFreelist, len = %d", len(a.indexFreelist))
+	}
+}
+
+func TestAllocatorRecyclesList(t *testing.T) {
+	a := NewFreelistAllocator()
+	v := a.AllocList()
+	sv := v.(*structValue)
+	sl := sv.ListValue
+	sl.Items = append(sl.Items, a.IntValue(1))
+	a.Free(sl)
+	a.Free(sv)
+
+	v2 := a.AllocList()
+	if v2.(*structValue).ListValue != sl {
+		t.Fatal("AllocList did not reuse the freed *structList")
+	}
+	if len(sl.Items) != 0 {
+		t.Fatalf("recycled list was not reset, len(Items) = %d", len(sl.Items))
+	}
+}
+
+func TestAllocatorRecyclesFields(t *testing.T) {
+	a := NewFreelistAllocator()
+	fs := a.AllocFields(4)
+	fs = append(fs, Field{Name: "a"}, Field{Name: "b"})
+	a.Free(fs)
+
+	fs2 := a.AllocFields(2)
+	if cap(fs2) < 4 {
+		t.Fatalf("AllocFields(2) after freeing a cap-4 slice returned cap %d", cap(fs2))
+	}
+	if len(fs2) != 0 {
+		t.Fatalf("recycled fields slice was not truncated to empty, len = %d", len(fs2))
+	}
+}
+
+func TestAllocatorFreeClearsIndexEntries(t *testing.T) {
+	a := NewFreelistAllocator()
+	idx := a.allocIndex()
+	f := Field{Name: "k"}
+	idx["k"] = &f
+	a.Free(idx)
+
+	idx2 := a.allocIndex()
+	if len(idx2) != 0 {
+		t.Fatalf("Free did not clear the index map before pooling it, len = %d", len(idx2))
+	}
+}
+
+func TestSimpleAllocatorFreeIsNoop(t *testing.T) {
+	v := SimpleAllocator.AllocValue()
+	SimpleAllocator.Free(v)
+
+	if len(SimpleAllocator.valueFreelist) != 0 {
+		t.Fatal("Free populated SimpleAllocator's freelist; it must be a no-op")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+// ToUnstructured converts any Value into plain Go values
+// (map[string]interface{}, []interface{}, string, int64, float64, bool, or
+// nil), regardless of which backend produced it. This is mostly useful for
+// feeding a Value into code (e.g. encoding/json) that only understands the
+// unstructured representation.
+func ToUnstructured(v Value) interface{} {
+	switch {
+	case v.IsNull():
+		return nil
+	case v.IsFloat():
+		return v.AsFloat()
+	case v.IsInt():
+		return v.AsInt()
+	case v.IsString():
+		return v.AsString()
+	case v.IsBool():
+		return v.AsBool()
+	case v.IsList():
+		l := v.AsList()
+		out := make([]interface{}, l.Length())
+		for i := range out {
+			out[i] = ToUnstructured(l.At(i))
+		}
+		return out
+	case v.IsMap():
+		out := map[string]interface{}{}
+		v.AsMap().Iterate(func(k string, val Value) bool {
+			out[k] = ToUnstructured(val)
+			return true
+		})
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Decoder reads a stream of JSON documents from an io.Reader and turns
+// each one directly into a Value, without first building an intermediate
+// map[string]interface{}/[]interface{} tree the way json.Unmarshal plus
+// NewValueUnstructured would. For a large object this halves the garbage
+// produced per decode: encoding/json's token stream is read once, straight
+// into the Map/List/scalar types this package already uses.
+//
+// A Decoder is not safe for concurrent use.
+type Decoder struct {
+	dec       *json.Decoder
+	allocator *Allocator
+}
+
+// NewJSONDecoder returns a Decoder that reads successive JSON documents
+// from r, allocating each Value tree fresh.
+func NewJSONDecoder(r io.Reader) *Decoder {
+	return NewJSONDecoderWithAllocator(r, NewFreelistAllocator())
+}
+
+// NewJSONDecoderWithAllocator is like NewJSONDecoder, but the returned
+// Decoder uses a to allocate and recycle the Values, Maps, Lists and Fields
+// it builds. This is worth doing when decoding many documents in sequence
+// (e.g. while diffing every object in a large list), since it lets one
+// document's storage be reused for the next once the caller is done with
+// it. See Allocator.
+func NewJSONDecoderWithAllocator(r io.Reader, a *Allocator) *Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &Decoder{dec: dec, allocator: a}
+}
+
+// Decode reads the next JSON document from the stream and returns it as a
+// Value. It returns io.EOF once the stream is exhausted, matching
+// json.Decoder.Decode.
+func (d *Decoder) Decode() (Value, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeValue(tok)
+}
+
+func (d *Decoder) decodeValue(tok json.Token) (Value, error) {
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return d.decodeObject()
+		case '[':
+			return d.decodeArray()
+		default:
+			// '}' and ']' are consumed by decodeObject/decodeArray and
+			// should never reach decodeValue on their own.
+			return nil, fmt.Errorf("value: unexpected delimiter %q", t)
+		}
+	case json.Number:
+		return d.allocator.numberValue(t)
+	case string:
+		return d.allocator.StringValue(t), nil
+	case bool:
+		return d.allocator.BooleanValue(t), nil
+	case nil:
+		return d.allocator.NullValue(), nil
+	default:
+		return nil, fmt.Errorf("value: unexpected token %#v", tok)
+	}
+}
+
+// numberValue parses n as an Int if it has no fractional or exponent part,
+// and as a Float otherwise, so that e.g. round-tripping a large int64
+// through this decoder doesn't silently lose precision the way decoding
+// straight into float64 would.
+func (a *Allocator) numberValue(n json.Number) (Value, error) {
+	if i, err := n.Int64(); err == nil {
+		// Build the Int directly rather than going through IntValue(int),
+		// which would truncate i on platforms where int is 32 bits.
+		v := a.allocStructValue()
+		i2 := Int(i)
+		v.IntValue = &i2
+		return v, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("value: invalid number %q: %v", n, err)
+	}
+	return a.FloatValue(f), nil
+}
+
+// decodeObject reads fields up to the object's closing '}', which it
+// consumes before returning.
+func (d *Decoder) decodeObject() (Value, error) {
+	v := d.allocator.AllocMap()
+	m := v.AsMap()
+	for d.dec.More() {
+		keyTok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return nil, fmt.Errorf("value: expected object key, got %#v", keyTok)
+		}
+		valTok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.decodeValue(valTok)
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, val)
+	}
+	if _, err := d.dec.Token(); err != nil { // consume '}'
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeArray reads items up to the array's closing ']', which it consumes
+// before returning.
+func (d *Decoder) decodeArray() (Value, error) {
+	v := d.allocator.AllocList()
+	sv := v.(*structValue)
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.decodeValue(tok)
+		if err != nil {
+			return nil, err
+		}
+		sv.ListValue.Items = append(sv.ListValue.Items, val)
+	}
+	if _, err := d.dec.Token(); err != nil { // consume ']'
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Encode writes v to w as JSON. Map keys are emitted in v.AsMap().Iterate
+// order rather than being sorted, so parse-modify-emit round trips (e.g.
+// via NewJSONDecoder) do not reshuffle a document's fields.
+func Encode(v Value, w io.Writer) error {
+	e := &encoder{w: w}
+	if err := e.encodeValue(v); err != nil {
+		return err
+	}
+	return e.err
+}
+
+// encoder writes JSON directly to w, byte by byte, rather than building an
+// intermediate []byte the way json.Marshal does--the same tradeoff Decoder
+// makes in reverse.
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) writeString(s string) {
+	e.write([]byte(s))
+}
+
+func (e *encoder) encodeValue(v Value) error {
+	switch {
+	case v.IsNull():
+		e.writeString("null")
+	case v.IsBool():
+		if v.AsBool() {
+			e.writeString("true")
+		} else {
+			e.writeString("false")
+		}
+	case v.IsInt():
+		e.writeString(fmt.Sprintf("%d", v.AsInt()))
+	case v.IsFloat():
+		b, err := json.Marshal(v.AsFloat())
+		if err != nil {
+			return err
+		}
+		e.write(b)
+	case v.IsString():
+		b, err := json.Marshal(v.AsString())
+		if err != nil {
+			return err
+		}
+		e.write(b)
+	case v.IsList():
+		e.encodeList(v.AsList())
+	case v.IsMap():
+		e.encodeMap(v.AsMap())
+	default:
+		return fmt.Errorf("value: Encode called on an invalid Value")
+	}
+	return e.err
+}
+
+func (e *encoder) encodeList(l List) {
+	e.writeString("[")
+	n := l.Length()
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			e.writeString(",")
+		}
+		if err := e.encodeValue(l.At(i)); err != nil {
+			e.err = err
+			return
+		}
+	}
+	e.writeString("]")
+}
+
+func (e *encoder) encodeMap(m Map) {
+	e.writeString("{")
+	first := true
+	m.Iterate(func(k string, val Value) bool {
+		if !first {
+			e.writeString(",")
+		}
+		first = false
+		b, err := json.Marshal(k)
+		if err != nil {
+			e.err = err
+			return false
+		}
+		e.write(b)
+		e.writeString(":")
+		if err := e.encodeValue(val); err != nil {
+			e.err = err
+			return false
+		}
+		return e.err == nil
+	})
+	e.writeString("}")
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsontagutil parses the `json:"..."` struct tag that the reflect
+// backend in the value package uses to map Go struct fields onto keys in a
+// Map Value.
+package jsontagutil
+
+import (
+	"reflect"
+	"strings"
+)
+
+// JSONTag holds the parsed pieces of a `json:"name,omitempty,inline"` tag.
+type JSONTag struct {
+	// Name is the JSON key for this field, or "" if the Go field name
+	// should be used (no name was given in the tag).
+	Name string
+	// Omit is true if the tag is exactly "-" or the field is unexported
+	// (and not embedded): the field is never (un)marshaled.
+	Omit bool
+	// OmitEmpty is true if the "omitempty" option was present.
+	OmitEmpty bool
+	// Inline is true if the "inline" option was present (this is not a
+	// standard encoding/json option, but is used by structured-merge-diff
+	// and other Kubernetes tooling to flatten an embedded struct's fields
+	// into its parent).
+	Inline bool
+}
+
+// LookupJSON parses the `json` tag on f, if any. As in encoding/json, an
+// unexported field is always omitted. For an untagged field, or a tag that
+// gives no name, Name is left "" and the caller uses the Go field name.
+func LookupJSON(f reflect.StructField) JSONTag {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return JSONTag{Omit: true}
+	}
+	if f.PkgPath != "" && !f.Anonymous {
+		// Unexported field; encoding/json never considers these.
+		return JSONTag{Omit: true}
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	info := JSONTag{Name: name}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			info.OmitEmpty = true
+		case "inline":
+			info.Inline = true
+		}
+	}
+	return info
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// List represents an ordered sequence of Values, regardless of the backend
+// that stores them (a parsed tree, a reflected Go slice/array, or an
+// unstructured []interface{}).
+type List interface {
+	// Length returns the number of items in the list.
+	Length() int
+	// At returns the item at the given index. It panics if i is out of
+	// range.
+	At(i int) Value
+}
+
+// listLess compares two lists lexically, regardless of the backend(s) that
+// produced them.
+func listLess(lhs, rhs List) bool {
+	i := 0
+	for {
+		if i >= lhs.Length() && i >= rhs.Length() {
+			// Lists are the same length and all items are equal.
+			return false
+		}
+		if i >= lhs.Length() {
+			// LHS is shorter.
+			return true
+		}
+		if i >= rhs.Length() {
+			// RHS is shorter.
+			return false
+		}
+		if Less(lhs.At(i), rhs.At(i)) {
+			return true
+		}
+		if Less(rhs.At(i), lhs.At(i)) {
+			return false
+		}
+		i++
+	}
+}
+
+// listEquals compares two lists for structural equality, regardless of the
+// backend(s) that produced them.
+func listEquals(lhs, rhs List) bool {
+	if lhs.Length() != rhs.Length() {
+		return false
+	}
+	for i := 0; i < lhs.Length(); i++ {
+		if !Equals(lhs.At(i), rhs.At(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// listHash combines listTypeTag and then the item hashes in list order,
+// since listEquals compares lists element-by-element in order.
+func listHash(l List) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(listTypeTag))
+	h.Write(buf[:])
+	for i := 0; i < l.Length(); i++ {
+		binary.LittleEndian.PutUint64(buf[:], Hash(l.At(i)))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// structList is the original, concrete List implementation: a slice of
+// Values.
+type structList struct {
+	Items []Value
+}
+
+// NewListValue constructs a Value backed by a freshly created, empty List
+// that callers can populate by appending to its Items.
+func NewListValue() Value {
+	return &structValue{ListValue: &structList{}}
+}
+
+// NewListValueWithAllocator is like NewListValue, but recycles its backing
+// structList from a. See Allocator.
+func NewListValueWithAllocator(a *Allocator) Value {
+	return a.AllocList()
+}
+
+// Length returns the number of items in the list.
+func (l *structList) Length() int {
+	return len(l.Items)
+}
+
+// At returns the item at the given index.
+func (l *structList) At(i int) Value {
+	return l.Items[i]
+}
@@ -0,0 +1,503 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "sort"
+
+// Map represents a set of key-value pairs, regardless of the backend that
+// stores them (a parsed tree, a reflected Go struct/map, or an unstructured
+// map[string]interface{}).
+type Map interface {
+	// Length returns how many key-value pairs are in the map.
+	Length() int
+	// Get returns the value for the given key, and whether it was present.
+	Get(key string) (Value, bool)
+	// Set inserts or updates the given key with the given value. It panics
+	// if the underlying implementation is read-only (e.g. some reflected
+	// values).
+	Set(key string, val Value)
+	// Delete removes the key from the map, if present. It panics if the
+	// underlying implementation cannot remove keys (e.g. reflected structs).
+	Delete(key string)
+	// Iterate calls fn for each key-value pair in the map until fn returns
+	// false. The order depends on the backend: insertion order for this
+	// package's own maps, field order for reflected structs, and Go's map
+	// iteration order, which can differ from call to call, for reflected
+	// and unstructured Go maps.
+	Iterate(fn func(key string, val Value) bool) bool
+}
+
+// mapLess compares two maps lexically, regardless of the backend(s) that
+// produced them.
+func mapLess(lhs, rhs Map) bool {
+	lk, rk := sortedKeys(lhs), sortedKeys(rhs)
+	i := 0
+	for {
+		if i >= len(lk) && i >= len(rk) {
+			// Maps are the same length and all items are equal.
+			return false
+		}
+		if i >= len(lk) {
+			// LHS is shorter.
+			return true
+		}
+		if i >= len(rk) {
+			// RHS is shorter.
+			return false
+		}
+		if lk[i] != rk[i] {
+			// the map having the field name that sorts lexically less is "less"
+			return lk[i] < rk[i]
+		}
+		lv, _ := lhs.Get(lk[i])
+		rv, _ := rhs.Get(rk[i])
+		if Less(lv, rv) {
+			return true
+		}
+		if Less(rv, lv) {
+			return false
+		}
+		i++
+	}
+}
+
+// mapEquals compares two maps for structural equality, regardless of the
+// backend(s) that produced them. Unlike listEquals, field order doesn't
+// matter: only the set of keys and their values do.
+func mapEquals(lhs, rhs Map) bool {
+	if lhs.Length() != rhs.Length() {
+		return false
+	}
+	return lhs.Iterate(func(k string, lv Value) bool {
+		rv, ok := rhs.Get(k)
+		return ok && Equals(lv, rv)
+	})
+}
+
+// mapHash combines per-field hashes with XOR, since mapEquals--unlike
+// listEquals--considers maps equal regardless of field order.
+func mapHash(m Map) uint64 {
+	h := uint64(mapTypeTag)
+	m.Iterate(func(k string, val Value) bool {
+		h ^= hashBytes(stringTypeTag, []byte(k))*1099511628211 + Hash(val)
+		return true
+	})
+	return h
+}
+
+func sortedKeys(m Map) []string {
+	keys := make([]string, 0, m.Length())
+	m.Iterate(func(k string, _ Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// Field is an individual key-value pair in a structMap.
+type Field struct {
+	Name  string
+	Value Value
+}
+
+// largeMapThreshold is the field count above which a structMap promotes
+// itself from a Go slice to a mapTree. Below it, the O(n) work that Delete
+// and the lazy index do is cheap and the slice's better locality wins;
+// above it, the repeated O(n) rebuilds start to show up in profiles of
+// large managed objects (e.g. CRDs with hundreds of fields), so the O(log
+// n) tree pays for itself.
+const largeMapThreshold = 128
+
+// structMap is the original, concrete Map implementation: a list of Fields
+// backed by a Go slice. It represents both structs and maps, preserving
+// field order. A lazily-constructed index makes repeated Get calls cheap.
+//
+// Once the map grows past largeMapThreshold fields, it promotes itself to
+// a mapTree (see promoteToTree) and Items/index go unused; Get, Set,
+// Delete and Iterate all check tree first and defer to it when present.
+//
+// TODO: Direct modifications to Items will cause serious problems; use Set
+// and Delete.
+type structMap struct {
+	Items []Field
+
+	// may be nil; lazily constructed.
+	index map[string]*Field
+
+	// may be nil; if set, used to recycle the index helper above and to
+	// allocate the Items slice Delete rebuilds, instead of allocating
+	// fresh ones.
+	allocator *Allocator
+
+	// may be nil; once non-nil, it is the map's sole backing storage and
+	// Items/index are left empty. See promoteToTree.
+	tree *mapTree
+}
+
+// NewMapValue constructs a Value backed by a freshly created, empty Map
+// that callers can populate with Set.
+func NewMapValue() Value {
+	return &structValue{MapValue: &structMap{}}
+}
+
+// NewMapValueWithAllocator is like NewMapValue, but the returned Map comes
+// from a's pool and recycles the index it builds lazily through a, rather
+// than allocating a new index each time a mutation invalidates the old
+// one. See Allocator.
+func NewMapValueWithAllocator(a *Allocator) Value {
+	return a.AllocMap()
+}
+
+// Length returns the number of fields in the map.
+func (m *structMap) Length() int {
+	if m.tree != nil {
+		return m.tree.size
+	}
+	return len(m.Items)
+}
+
+// Get returns the (Value, true) or (nil, false) if it is not present.
+func (m *structMap) Get(key string) (Value, bool) {
+	if m.tree != nil {
+		return m.tree.get(key)
+	}
+	f, ok := m.getField(key)
+	if !ok {
+		return nil, false
+	}
+	return f.Value, true
+}
+
+// freeIndex returns m.index to m.allocator's pool, if any, and clears it.
+// Callers must call this instead of setting m.index = nil directly
+// whenever a mutation invalidates the index, so the map backing it is
+// actually recycled rather than leaked until GC.
+func (m *structMap) freeIndex() {
+	if m.index != nil && m.allocator != nil {
+		m.allocator.Free(m.index)
+	}
+	m.index = nil
+}
+
+func (m *structMap) getField(key string) (*Field, bool) {
+	if m.index == nil {
+		if m.allocator != nil {
+			m.index = m.allocator.allocIndex()
+		} else {
+			m.index = map[string]*Field{}
+		}
+		for i := range m.Items {
+			f := &m.Items[i]
+			m.index[f.Name] = f
+		}
+	}
+	f, ok := m.index[key]
+	return f, ok
+}
+
+// Set inserts or updates the given item.
+func (m *structMap) Set(key string, val Value) {
+	if m.tree != nil {
+		m.tree.set(key, val)
+		return
+	}
+	if f, ok := m.getField(key); ok {
+		f.Value = val
+		return
+	}
+	m.Items = append(m.Items, Field{Name: key, Value: val})
+	m.freeIndex() // Since the append might have reallocated
+	if len(m.Items) > largeMapThreshold {
+		m.promoteToTree()
+	}
+}
+
+// Delete removes the key from the map.
+func (m *structMap) Delete(key string) {
+	if m.tree != nil {
+		m.tree.delete(key)
+		return
+	}
+	var items []Field
+	if m.allocator != nil {
+		items = m.allocator.AllocFields(len(m.Items))
+	} else {
+		items = make([]Field, 0, len(m.Items))
+	}
+	for i := range m.Items {
+		if m.Items[i].Name != key {
+			items = append(items, m.Items[i])
+		}
+	}
+	m.Items = items
+	m.freeIndex() // Since the list has changed
+}
+
+// Iterate visits the fields of the map in insertion order, so that
+// round-tripping a map through Iterate (e.g. via Encode) does not reshuffle
+// its fields.
+func (m *structMap) Iterate(fn func(key string, val Value) bool) bool {
+	if m.tree != nil {
+		return m.tree.iterate(fn)
+	}
+	for i := range m.Items {
+		if !fn(m.Items[i].Name, m.Items[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// promoteToTree moves the map's storage from Items to a mapTree, freeing
+// Items/index. Once called, m.tree is the map's sole backing store.
+func (m *structMap) promoteToTree() {
+	t := &mapTree{}
+	for i := range m.Items {
+		t.insert(m.Items[i].Name, m.Items[i].Value)
+	}
+	m.tree = t
+	m.Items = nil
+	m.freeIndex()
+}
+
+// mapTree is an AVL (height-balanced binary search) tree over Fields,
+// keyed by Name, giving Get/Set/Delete guaranteed O(log n) behavior even
+// when keys arrive already sorted--the case a plain, unbalanced BST
+// degrades to O(n) depth on, and one real managed-field/annotation key
+// sets hit often enough in practice (e.g. keys like "f:spec.containers").
+// It backs structMap once a map grows past largeMapThreshold fields.
+//
+// Name lookups alone don't preserve the field order structMap.Iterate
+// promises, so each node also carries a pointer into a side doubly-linked
+// list (head/tail below) threading every live field in insertion order;
+// iterate walks that list instead of the tree.
+type mapTree struct {
+	root *mapTreeNode
+	size int
+
+	// head/tail of the insertion-order list; see mapListNode.
+	head, tail *mapListNode
+}
+
+// mapListNode is one field in the tree's insertion-order side list. Each
+// mapTreeNode's listNode points to the mapListNode holding its Field, so
+// iteration order survives tree rotations and the successor-copy that
+// deleteNode does for two-child deletes.
+type mapListNode struct {
+	field      Field
+	prev, next *mapListNode
+}
+
+type mapTreeNode struct {
+	name        string
+	listNode    *mapListNode
+	left, right *mapTreeNode
+	height      int8
+}
+
+func treeNodeHeight(n *mapTreeNode) int8 {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *mapTreeNode) updateHeight() {
+	lh, rh := treeNodeHeight(n.left), treeNodeHeight(n.right)
+	if lh > rh {
+		n.height = lh + 1
+	} else {
+		n.height = rh + 1
+	}
+}
+
+func (n *mapTreeNode) balanceFactor() int8 {
+	return treeNodeHeight(n.left) - treeNodeHeight(n.right)
+}
+
+// rotateRight and rotateLeft are the standard AVL rebalancing rotations;
+// each returns the node that takes n's place in its parent.
+func (n *mapTreeNode) rotateRight() *mapTreeNode {
+	x := n.left
+	n.left = x.right
+	x.right = n
+	n.updateHeight()
+	x.updateHeight()
+	return x
+}
+
+func (n *mapTreeNode) rotateLeft() *mapTreeNode {
+	x := n.right
+	n.right = x.left
+	x.left = n
+	n.updateHeight()
+	x.updateHeight()
+	return x
+}
+
+// rebalanceTree restores the AVL height invariant (children's heights differ
+// by at most 1) at n, which may have just grown or shrunk by one level on
+// one side. Callers must call it on every node along the path back to the
+// root after an insert or delete.
+func rebalanceTree(n *mapTreeNode) *mapTreeNode {
+	n.updateHeight()
+	switch bf := n.balanceFactor(); {
+	case bf > 1:
+		if n.left.balanceFactor() < 0 {
+			n.left = n.left.rotateLeft()
+		}
+		return n.rotateRight()
+	case bf < -1:
+		if n.right.balanceFactor() > 0 {
+			n.right = n.right.rotateRight()
+		}
+		return n.rotateLeft()
+	default:
+		return n
+	}
+}
+
+func (t *mapTree) findNode(key string) *mapTreeNode {
+	n := t.root
+	for n != nil {
+		switch {
+		case key < n.name:
+			n = n.left
+		case key > n.name:
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+func (t *mapTree) get(key string) (Value, bool) {
+	n := t.findNode(key)
+	if n == nil {
+		return nil, false
+	}
+	return n.listNode.field.Value, true
+}
+
+// pushBack appends a new field to the tail of the insertion-order list.
+func (t *mapTree) pushBack(key string, val Value) *mapListNode {
+	ln := &mapListNode{field: Field{Name: key, Value: val}, prev: t.tail}
+	if t.tail != nil {
+		t.tail.next = ln
+	} else {
+		t.head = ln
+	}
+	t.tail = ln
+	return ln
+}
+
+func (t *mapTree) unlink(ln *mapListNode) {
+	if ln.prev != nil {
+		ln.prev.next = ln.next
+	} else {
+		t.head = ln.next
+	}
+	if ln.next != nil {
+		ln.next.prev = ln.prev
+	} else {
+		t.tail = ln.prev
+	}
+}
+
+// set inserts a new field or updates an existing one.
+func (t *mapTree) set(key string, val Value) {
+	if n := t.findNode(key); n != nil {
+		n.listNode.field.Value = val
+		return
+	}
+	t.insert(key, val)
+}
+
+// insert adds a field known not to already be present, skipping the
+// lookup set otherwise has to do. Used by promoteToTree, whose source
+// Items slice cannot contain duplicate names.
+func (t *mapTree) insert(key string, val Value) {
+	ln := t.pushBack(key, val)
+	t.root = t.insertNode(t.root, key, ln)
+	t.size++
+}
+
+func (t *mapTree) insertNode(n *mapTreeNode, key string, ln *mapListNode) *mapTreeNode {
+	if n == nil {
+		return &mapTreeNode{name: key, listNode: ln, height: 1}
+	}
+	if key < n.name {
+		n.left = t.insertNode(n.left, key, ln)
+	} else {
+		n.right = t.insertNode(n.right, key, ln)
+	}
+	return rebalanceTree(n)
+}
+
+func (t *mapTree) delete(key string) {
+	n := t.findNode(key)
+	if n == nil {
+		return
+	}
+	t.unlink(n.listNode)
+	t.root = t.deleteNode(t.root, key)
+	t.size--
+}
+
+// deleteNode removes the node named key from the subtree rooted at n,
+// rebalancing along the way, and returns the new subtree root. For a node
+// with two children it copies its in-order successor's name/listNode in
+// place (the listNode, not the Field, so the side list is untouched) and
+// recurses to remove that successor's now-duplicate node instead.
+func (t *mapTree) deleteNode(n *mapTreeNode, key string) *mapTreeNode {
+	if n == nil {
+		return nil
+	}
+	switch {
+	case key < n.name:
+		n.left = t.deleteNode(n.left, key)
+	case key > n.name:
+		n.right = t.deleteNode(n.right, key)
+	default:
+		if n.left == nil {
+			return n.right
+		}
+		if n.right == nil {
+			return n.left
+		}
+		succ := n.right
+		for succ.left != nil {
+			succ = succ.left
+		}
+		n.name, n.listNode = succ.name, succ.listNode
+		n.right = t.deleteNode(n.right, succ.name)
+	}
+	return rebalanceTree(n)
+}
+
+// iterate visits fields in insertion order, stopping early if fn returns
+// false.
+func (t *mapTree) iterate(fn func(key string, val Value) bool) bool {
+	for ln := t.head; ln != nil; ln = ln.next {
+		if !fn(ln.field.Name, ln.field.Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStructMapGetSetDelete(t *testing.T) {
+	m := &structMap{}
+
+	if _, ok := m.Get("a"); ok {
+		t.Fatal("Get found a key in an empty map")
+	}
+
+	m.Set("a", StringValue("1"))
+	m.Set("b", StringValue("2"))
+	if got, ok := m.Get("a"); !ok || got.AsString() != "1" {
+		t.Fatalf("Get(%q) = %v, %v", "a", got, ok)
+	}
+
+	m.Set("a", StringValue("3"))
+	if got, ok := m.Get("a"); !ok || got.AsString() != "3" {
+		t.Fatalf("Set did not update existing key, Get(%q) = %v, %v", "a", got, ok)
+	}
+	if m.Length() != 2 {
+		t.Fatalf("Length() = %d, want 2", m.Length())
+	}
+
+	m.Delete("a")
+	if _, ok := m.Get("a"); ok {
+		t.Fatal("Get found a key after Delete")
+	}
+	if m.Length() != 1 {
+		t.Fatalf("Length() = %d, want 1", m.Length())
+	}
+
+	// Deleting an absent key is a no-op.
+	m.Delete("missing")
+	if m.Length() != 1 {
+		t.Fatalf("Length() = %d after deleting an absent key, want 1", m.Length())
+	}
+}
+
+func TestStructMapIterateOrder(t *testing.T) {
+	m := &structMap{}
+	keys := []string{"z", "a", "m", "b"}
+	for _, k := range keys {
+		m.Set(k, StringValue(k))
+	}
+
+	var got []string
+	m.Iterate(func(k string, _ Value) bool {
+		got = append(got, k)
+		return true
+	})
+	if len(got) != len(keys) {
+		t.Fatalf("Iterate visited %d keys, want %d", len(got), len(keys))
+	}
+	for i, k := range keys {
+		if got[i] != k {
+			t.Fatalf("Iterate order = %v, want insertion order %v", got, keys)
+		}
+	}
+}
+
+func TestStructMapIterateStopsEarly(t *testing.T) {
+	m := &structMap{}
+	m.Set("a", StringValue("1"))
+	m.Set("b", StringValue("2"))
+	m.Set("c", StringValue("3"))
+
+	n := 0
+	all := m.Iterate(func(k string, _ Value) bool {
+		n++
+		return false
+	})
+	if all {
+		t.Fatal("Iterate returned true despite fn returning false")
+	}
+	if n != 1 {
+		t.Fatalf("Iterate called fn %d times after it returned false, want 1", n)
+	}
+}
+
+func TestStructMapPromotesToTree(t *testing.T) {
+	m := &structMap{}
+	for i := 0; i < largeMapThreshold+1; i++ {
+		m.Set(fmt.Sprintf("k%03d", i), IntValue(i))
+	}
+	if m.tree == nil {
+		t.Fatal("map did not promote itself to a tree past largeMapThreshold")
+	}
+	if m.Items != nil {
+		t.Fatal("promoteToTree did not clear Items")
+	}
+	if got := m.Length(); got != largeMapThreshold+1 {
+		t.Fatalf("Length() after promotion = %d, want %d", got, largeMapThreshold+1)
+	}
+	for i := 0; i < largeMapThreshold+1; i++ {
+		key := fmt.Sprintf("k%03d", i)
+		if got, ok := m.Get(key); !ok || got.AsInt() != int64(i) {
+			t.Fatalf("Get(%q) after promotion = %v, %v, want %d", key, got, ok, i)
+		}
+	}
+}
+
+func TestStructMapOrderPreservedThroughPromotion(t *testing.T) {
+	m := &structMap{}
+	var keys []string
+	for i := 0; i < largeMapThreshold+10; i++ {
+		// Insert in reverse-sorted order so the tree's key order and the
+		// insertion order disagree, to catch Iterate silently falling back
+		// to tree (sorted) order instead of the insertion-order list.
+		k := fmt.Sprintf("k%03d", largeMapThreshold+10-i)
+		keys = append(keys, k)
+		m.Set(k, StringValue(k))
+	}
+	if m.tree == nil {
+		t.Fatal("map did not promote itself to a tree")
+	}
+
+	var got []string
+	m.Iterate(func(k string, _ Value) bool {
+		got = append(got, k)
+		return true
+	})
+	if len(got) != len(keys) {
+		t.Fatalf("Iterate visited %d keys, want %d", len(got), len(keys))
+	}
+	for i, k := range keys {
+		if got[i] != k {
+			t.Fatalf("Iterate order diverged from insertion order at index %d: got %q, want %q", i, got[i], k)
+		}
+	}
+}
+
+func TestStructMapTreeDeleteAndOrder(t *testing.T) {
+	m := &structMap{}
+	var keys []string
+	for i := 0; i < largeMapThreshold+20; i++ {
+		k := fmt.Sprintf("k%03d", i)
+		keys = append(keys, k)
+		m.Set(k, StringValue(k))
+	}
+	if m.tree == nil {
+		t.Fatal("map did not promote itself to a tree")
+	}
+
+	// Delete every third key, including the root and keys with two
+	// children, to exercise deleteNode's successor-copy path.
+	var want []string
+	for i, k := range keys {
+		if i%3 == 0 {
+			m.Delete(k)
+			continue
+		}
+		want = append(want, k)
+	}
+	if got := m.Length(); got != len(want) {
+		t.Fatalf("Length() after deletes = %d, want %d", got, len(want))
+	}
+	for i, k := range keys {
+		_, ok := m.Get(k)
+		wantPresent := i%3 != 0
+		if ok != wantPresent {
+			t.Fatalf("Get(%q) present = %v, want %v", k, ok, wantPresent)
+		}
+	}
+
+	var got []string
+	m.Iterate(func(k string, _ Value) bool {
+		got = append(got, k)
+		return true
+	})
+	if len(got) != len(want) {
+		t.Fatalf("Iterate visited %d keys after deletes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Iterate order after deletes = %v, want %v", got, want)
+		}
+	}
+}
+
+// TestMapTreeStaysBalanced inserts keys already in sorted order--the case a
+// plain unbalanced BST degrades to O(n) depth on--and checks the AVL height
+// invariant holds at every node, and that the resulting height is
+// logarithmic rather than linear in the key count.
+func TestMapTreeStaysBalanced(t *testing.T) {
+	tr := &mapTree{}
+	const n = 1000
+	for i := 0; i < n; i++ {
+		tr.insert(fmt.Sprintf("k%05d", i), IntValue(i))
+	}
+
+	var checkBalanced func(n *mapTreeNode) int8
+	checkBalanced = func(node *mapTreeNode) int8 {
+		if node == nil {
+			return 0
+		}
+		lh := checkBalanced(node.left)
+		rh := checkBalanced(node.right)
+		bf := lh - rh
+		if bf > 1 || bf < -1 {
+			t.Fatalf("node %q unbalanced: left height %d, right height %d", node.name, lh, rh)
+		}
+		if lh > rh {
+			return lh + 1
+		}
+		return rh + 1
+	}
+	height := checkBalanced(tr.root)
+
+	// A balanced tree over n nodes has height O(log2 n); a degenerate
+	// unbalanced BST fed sorted input would have height n. 2*log2(n+1) is a
+	// generous bound that still fails on a linear chain.
+	maxHeight := int8(0)
+	for v := n + 1; v > 0; v >>= 1 {
+		maxHeight++
+	}
+	maxHeight *= 2
+	if height > maxHeight {
+		t.Fatalf("tree height %d exceeds balanced bound %d for %d sorted inserts", height, maxHeight, n)
+	}
+}
+
+func TestMapTreeSetUpdatesExisting(t *testing.T) {
+	tr := &mapTree{}
+	tr.insert("a", StringValue("1"))
+	tr.set("a", StringValue("2"))
+	if got, ok := tr.get("a"); !ok || got.AsString() != "2" {
+		t.Fatalf("get(%q) after set-update = %v, %v, want \"2\"", "a", got, ok)
+	}
+	if tr.size != 1 {
+		t.Fatalf("set-update changed size to %d, want 1", tr.size)
+	}
+}
+
+func TestMapEqualsIgnoresOrder(t *testing.T) {
+	lhs := &structMap{}
+	lhs.Set("a", StringValue("1"))
+	lhs.Set("b", StringValue("2"))
+
+	rhs := &structMap{}
+	rhs.Set("b", StringValue("2"))
+	rhs.Set("a", StringValue("1"))
+
+	if !mapEquals(lhs, rhs) {
+		t.Fatal("mapEquals treated differently-ordered but equal maps as unequal")
+	}
+
+	rhs.Set("a", StringValue("3"))
+	if mapEquals(lhs, rhs) {
+		t.Fatal("mapEquals treated maps with a differing value as equal")
+	}
+}
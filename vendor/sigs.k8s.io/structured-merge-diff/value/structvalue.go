@@ -0,0 +1,75 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+// structValue is the original, concrete Value implementation: a struct with
+// exactly one of its fields set. It is produced by the scalar constructors
+// below and by NewMapValue/NewListValue.
+type structValue struct {
+	FloatValue   *Float
+	IntValue     *Int
+	StringValue  *String
+	BooleanValue *Boolean
+	ListValue    *structList
+	MapValue     *structMap
+	Null         bool // represents an explicit `"foo" = null`
+}
+
+type Int int64
+type Float float64
+type String string
+type Boolean bool
+
+// StringValue returns s as a scalar string Value.
+func StringValue(s string) Value { return SimpleAllocator.StringValue(s) }
+
+// IntValue returns i as a scalar numeric (integer) Value.
+func IntValue(i int) Value { return SimpleAllocator.IntValue(i) }
+
+// FloatValue returns f as a scalar numeric (float) Value.
+func FloatValue(f float64) Value { return SimpleAllocator.FloatValue(f) }
+
+// BooleanValue returns b as a scalar boolean Value.
+func BooleanValue(b bool) Value { return SimpleAllocator.BooleanValue(b) }
+
+// NullValue returns an explicit null Value.
+func NullValue() Value { return SimpleAllocator.NullValue() }
+
+func (v *structValue) IsMap() bool    { return v.MapValue != nil }
+func (v *structValue) IsList() bool   { return v.ListValue != nil }
+func (v *structValue) IsFloat() bool  { return v.FloatValue != nil }
+func (v *structValue) IsInt() bool    { return v.IntValue != nil }
+func (v *structValue) IsString() bool { return v.StringValue != nil }
+func (v *structValue) IsBool() bool   { return v.BooleanValue != nil }
+func (v *structValue) IsNull() bool   { return v.Null }
+
+func (v *structValue) AsMap() Map    { return v.MapValue }
+func (v *structValue) AsList() List  { return v.ListValue }
+func (v *structValue) AsFloat() float64 {
+	if v.FloatValue != nil {
+		return float64(*v.FloatValue)
+	}
+	return float64(*v.IntValue)
+}
+func (v *structValue) AsInt() int64      { return int64(*v.IntValue) }
+func (v *structValue) AsString() string  { return string(*v.StringValue) }
+func (v *structValue) AsBool() bool      { return bool(*v.BooleanValue) }
+
+func (v *structValue) Less(rhs Value) bool   { return Less(v, rhs) }
+func (v *structValue) Equals(rhs Value) bool { return Equals(v, rhs) }
+func (v *structValue) Hash() uint64          { return Hash(v) }
+func (v *structValue) String() string        { return ToString(v) }
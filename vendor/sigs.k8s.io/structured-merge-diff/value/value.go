@@ -14,98 +14,149 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// Package value defines a generic, type-erased representation of JSON-like
+// data (the values that a schema describes). A Value can be backed by
+// several different underlying representations--a parsed tree of the
+// original structs in this package, a Go struct/map/slice inspected through
+// reflection, or an already-decoded map[string]interface{}/[]interface{}
+// ("unstructured")--without the caller having to know which one it has.
 package value
 
 import (
+	"encoding/binary"
 	"fmt"
-	"sort"
+	"hash/fnv"
+	"math"
 	"strings"
 )
 
-// A Value is an object; it corresponds to an 'atom' in the schema.
-type Value struct {
-	// Exactly one of the below must be set.
-	FloatValue   *Float
-	IntValue     *Int
-	StringValue  *String
-	BooleanValue *Boolean
-	ListValue    *List
-	MapValue     *Map
-	Null         bool // represents an explicit `"foo" = null`
+// A Value corresponds to an 'atom' in an object's schema. It may be backed
+// by this package's own Map/List/scalar types, by a reflected Go value, or
+// by an unstructured map[string]interface{}/[]interface{} tree; callers
+// should not care which.
+type Value interface {
+	// IsMap returns true if the Value is a Map.
+	IsMap() bool
+	// IsList returns true if the Value is a List.
+	IsList() bool
+	// IsFloat returns true if the Value is a float.
+	IsFloat() bool
+	// IsInt returns true if the Value is an int.
+	IsInt() bool
+	// IsString returns true if the Value is a string.
+	IsString() bool
+	// IsBool returns true if the Value is a boolean.
+	IsBool() bool
+	// IsNull returns true if the Value is null.
+	IsNull() bool
+
+	// AsMap converts the Value into a Map (or panics if it is not a Map).
+	AsMap() Map
+	// AsList converts the Value into a List (or panics if it is not a List).
+	AsList() List
+	// AsFloat converts the Value into a float (or panics if it is not a
+	// float or an int; ints are widened to float64).
+	AsFloat() float64
+	// AsInt converts the Value into an int64 (or panics if it is not an int).
+	AsInt() int64
+	// AsString converts the Value into a string (or panics if it is not a
+	// string).
+	AsString() string
+	// AsBool converts the Value into a bool (or panics if it is not a bool).
+	AsBool() bool
+
+	// Less provides a total ordering for Value (so that they can be sorted,
+	// even if they are of different types).
+	Less(rhs Value) bool
+
+	// Equals returns true if this Value is structurally equal to rhs,
+	// regardless of which backend(s) produced them.
+	Equals(rhs Value) bool
+
+	// Hash returns a hash of this Value's structural content, such that
+	// a.Equals(b) implies a.Hash() == b.Hash(), regardless of which
+	// backend(s) produced a and b. It does not provide collision
+	// resistance.
+	Hash() uint64
+
+	// String returns a human-readable representation of the value.
+	String() string
 }
 
-// Less provides a total ordering for Value (so that they can be sorted, even
-// if they are of different types).
-func (v Value) Less(rhs Value) bool {
-	if v.FloatValue != nil {
-		if rhs.FloatValue == nil {
+// Less provides a total ordering of any two Values, regardless of what
+// backend(s) produced them. All the concrete Value implementations in this
+// package delegate their Less method to this function so that, e.g., a
+// reflected int can be compared against a parsed Float.
+func Less(lhs, rhs Value) bool {
+	if lhs.IsFloat() {
+		if !rhs.IsFloat() {
 			// Extra: compare floats and ints numerically.
-			if rhs.IntValue != nil {
-				return float64(*v.FloatValue) < float64(*rhs.IntValue)
+			if rhs.IsInt() {
+				return lhs.AsFloat() < float64(rhs.AsInt())
 			}
 			return true
 		}
-		return *v.FloatValue < *rhs.FloatValue
-	} else if rhs.FloatValue != nil {
+		return lhs.AsFloat() < rhs.AsFloat()
+	} else if rhs.IsFloat() {
 		// Extra: compare floats and ints numerically.
-		if v.IntValue != nil {
-			return float64(*v.IntValue) < float64(*rhs.FloatValue)
+		if lhs.IsInt() {
+			return float64(lhs.AsInt()) < rhs.AsFloat()
 		}
 		return false
 	}
 
-	if v.IntValue != nil {
-		if rhs.IntValue == nil {
+	if lhs.IsInt() {
+		if !rhs.IsInt() {
 			return true
 		}
-		return *v.IntValue < *rhs.IntValue
-	} else if rhs.IntValue != nil {
+		return lhs.AsInt() < rhs.AsInt()
+	} else if rhs.IsInt() {
 		return false
 	}
 
-	if v.StringValue != nil {
-		if rhs.StringValue == nil {
+	if lhs.IsString() {
+		if !rhs.IsString() {
 			return true
 		}
-		return *v.StringValue < *rhs.StringValue
-	} else if rhs.StringValue != nil {
+		return lhs.AsString() < rhs.AsString()
+	} else if rhs.IsString() {
 		return false
 	}
 
-	if v.BooleanValue != nil {
-		if rhs.BooleanValue == nil {
+	if lhs.IsBool() {
+		if !rhs.IsBool() {
 			return true
 		}
-		if *v.BooleanValue == *rhs.BooleanValue {
+		if lhs.AsBool() == rhs.AsBool() {
 			return false
 		}
-		return *v.BooleanValue == false
-	} else if rhs.BooleanValue != nil {
+		return lhs.AsBool() == false
+	} else if rhs.IsBool() {
 		return false
 	}
 
-	if v.ListValue != nil {
-		if rhs.ListValue == nil {
+	if lhs.IsList() {
+		if !rhs.IsList() {
 			return true
 		}
-		return v.ListValue.Less(rhs.ListValue)
-	} else if rhs.ListValue != nil {
+		return listLess(lhs.AsList(), rhs.AsList())
+	} else if rhs.IsList() {
 		return false
 	}
-	if v.MapValue != nil {
-		if rhs.MapValue == nil {
+	if lhs.IsMap() {
+		if !rhs.IsMap() {
 			return true
 		}
-		return v.MapValue.Less(rhs.MapValue)
-	} else if rhs.MapValue != nil {
+		return mapLess(lhs.AsMap(), rhs.AsMap())
+	} else if rhs.IsMap() {
 		return false
 	}
-	if v.Null {
-		if !rhs.Null {
+	if lhs.IsNull() {
+		if !rhs.IsNull() {
 			return true
 		}
 		return false
-	} else if rhs.Null {
+	} else if rhs.IsNull() {
 		return false
 	}
 
@@ -113,202 +164,127 @@ func (v Value) Less(rhs Value) bool {
 	return false
 }
 
-type Int int64
-type Float float64
-type String string
-type Boolean bool
-
-// Field is an individual key-value pair.
-type Field struct {
-	Name  string
-	Value Value
-}
-
-// List is a list of items.
-type List struct {
-	Items []Value
-}
-
-// Less compares two lists lexically.
-func (l *List) Less(rhs *List) bool {
-	i := 0
-	for {
-		if i >= len(l.Items) && i >= len(rhs.Items) {
-			// Lists are the same length and all items are equal.
+// Equals returns true if lhs and rhs are structurally equal, regardless of
+// which backend(s) produced them. It mirrors Less's type-by-type
+// comparisons (including widening an Int to compare numerically against a
+// Float) so that two Values compare Equal exactly when neither sorts
+// before the other under Less.
+func Equals(lhs, rhs Value) bool {
+	if lhs.IsFloat() || rhs.IsFloat() {
+		if !(lhs.IsFloat() || lhs.IsInt()) || !(rhs.IsFloat() || rhs.IsInt()) {
 			return false
 		}
-		if i >= len(l.Items) {
-			// LHS is shorter.
-			return true
-		}
-		if i >= len(rhs.Items) {
-			// RHS is shorter.
-			return false
-		}
-		if l.Items[i].Less(rhs.Items[i]) {
-			// LHS is less; return
-			return true
-		}
-		if rhs.Items[i].Less(l.Items[i]) {
-			// RHS is less; return
-			return false
-		}
-		// The items are equal; continue.
-		i++
+		return lhs.AsFloat() == rhs.AsFloat()
 	}
-}
-
-// Map is a map of key-value pairs. It represents both structs and maps. We use
-// a list and a go-language map to preserve order.
-//
-// Set and Get helpers are provided.
-type Map struct {
-	Items []Field
-
-	// may be nil; lazily constructed.
-	// TODO: Direct modifications to Items above will cause serious problems.
-	index map[string]*Field
-	// may be empty; lazily constructed.
-	// TODO: Direct modifications to Items above will cause serious problems.
-	order []int
-}
-
-func (m *Map) computeOrder() {
-	if len(m.order) != len(m.Items) {
-		m.order = make([]int, len(m.Items))
-		for i := range m.order {
-			m.order[i] = i
-		}
-		sort.SliceStable(m.order, func(i, j int) bool {
-			return m.Items[m.order[i]].Name < m.Items[m.order[j]].Name
-		})
+	if lhs.IsInt() || rhs.IsInt() {
+		return lhs.IsInt() && rhs.IsInt() && lhs.AsInt() == rhs.AsInt()
 	}
-}
-
-// Less compares two maps lexically.
-func (m *Map) Less(rhs *Map) bool {
-	m.computeOrder()
-	rhs.computeOrder()
-
-	i := 0
-	for {
-		if i >= len(m.order) && i >= len(rhs.order) {
-			// Maps are the same length and all items are equal.
-			return false
-		}
-		if i >= len(m.order) {
-			// LHS is shorter.
-			return true
-		}
-		if i >= len(rhs.order) {
-			// RHS is shorter.
-			return false
-		}
-		fa, fb := &m.Items[m.order[i]], &rhs.Items[rhs.order[i]]
-		if fa.Name != fb.Name {
-			// the map having the field name that sorts lexically less is "less"
-			return fa.Name < fb.Name
-		}
-		if fa.Value.Less(fb.Value) {
-			// LHS is less; return
-			return true
-		}
-		if fb.Value.Less(fa.Value) {
-			// RHS is less; return
-			return false
-		}
-		// The items are equal; continue.
-		i++
+	if lhs.IsString() || rhs.IsString() {
+		return lhs.IsString() && rhs.IsString() && lhs.AsString() == rhs.AsString()
 	}
-}
-
-// Get returns the (Field, true) or (nil, false) if it is not present
-func (m *Map) Get(key string) (*Field, bool) {
-	if m.index == nil {
-		m.index = map[string]*Field{}
-		for i := range m.Items {
-			f := &m.Items[i]
-			m.index[f.Name] = f
-		}
+	if lhs.IsBool() || rhs.IsBool() {
+		return lhs.IsBool() && rhs.IsBool() && lhs.AsBool() == rhs.AsBool()
 	}
-	f, ok := m.index[key]
-	return f, ok
-}
-
-// Set inserts or updates the given item.
-func (m *Map) Set(key string, value Value) {
-	if f, ok := m.Get(key); ok {
-		f.Value = value
-		return
+	if lhs.IsList() || rhs.IsList() {
+		return lhs.IsList() && rhs.IsList() && listEquals(lhs.AsList(), rhs.AsList())
 	}
-	m.Items = append(m.Items, Field{Name: key, Value: value})
-	m.index = nil // Since the append might have reallocated
-	m.order = nil
-}
-
-// Delete removes the key from the set.
-func (m *Map) Delete(key string) {
-	items := []Field{}
-	for i := range m.Items {
-		if m.Items[i].Name != key {
-			items = append(items, m.Items[i])
-		}
+	if lhs.IsMap() || rhs.IsMap() {
+		return lhs.IsMap() && rhs.IsMap() && mapEquals(lhs.AsMap(), rhs.AsMap())
 	}
-	m.Items = items
-	m.index = nil // Since the list has changed
-	m.order = nil
+	// Both null, or both invalid (nothing set).
+	return lhs.IsNull() == rhs.IsNull()
 }
 
-// StringValue returns s as a scalar string Value.
-func StringValue(s string) Value {
-	s2 := String(s)
-	return Value{StringValue: &s2}
-}
+// valueTypeTag distinguishes the type of a Value in its Hash, so that,
+// e.g., an empty map and an empty list don't collide.
+type valueTypeTag uint64
 
-// IntValue returns i as a scalar numeric (integer) Value.
-func IntValue(i int) Value {
-	i2 := Int(i)
-	return Value{IntValue: &i2}
+const (
+	nullTypeTag valueTypeTag = iota + 1
+	boolTypeTag
+	numberTypeTag
+	stringTypeTag
+	listTypeTag
+	mapTypeTag
+)
+
+// Hash returns a hash of v's structural content, such that Equals(a, b)
+// implies Hash(a) == Hash(b), regardless of which backend(s) produced a
+// and b. It does not provide collision resistance.
+func Hash(v Value) uint64 {
+	switch {
+	case v.IsFloat():
+		return hashNumber(v.AsFloat())
+	case v.IsInt():
+		// Hash as a float so that an Int and an equal-valued Float (see
+		// Equals) hash the same.
+		return hashNumber(float64(v.AsInt()))
+	case v.IsString():
+		return hashBytes(stringTypeTag, []byte(v.AsString()))
+	case v.IsBool():
+		if v.AsBool() {
+			return hashBytes(boolTypeTag, []byte{1})
+		}
+		return hashBytes(boolTypeTag, []byte{0})
+	case v.IsList():
+		return listHash(v.AsList())
+	case v.IsMap():
+		return mapHash(v.AsMap())
+	case v.IsNull():
+		return uint64(nullTypeTag)
+	default:
+		// Invalid Value-- nothing is set.
+		return 0
+	}
 }
 
-// FloatValue returns f as a scalar numeric (float) Value.
-func FloatValue(f float64) Value {
-	f2 := Float(f)
-	return Value{FloatValue: &f2}
+func hashNumber(f float64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	return hashBytes(numberTypeTag, buf[:])
 }
 
-// BooleanValue returns b as a scalar boolean Value.
-func BooleanValue(b bool) Value {
-	b2 := Boolean(b)
-	return Value{BooleanValue: &b2}
+// hashBytes combines a type tag with b's content into a single FNV-1a hash,
+// so that values of different types never collide just because their
+// encoded bytes happen to match.
+func hashBytes(tag valueTypeTag, b []byte) uint64 {
+	h := fnv.New64a()
+	var tagBuf [8]byte
+	binary.LittleEndian.PutUint64(tagBuf[:], uint64(tag))
+	h.Write(tagBuf[:])
+	h.Write(b)
+	return h.Sum64()
 }
 
-// String returns a human-readable representation of the value.
-func (v Value) String() string {
+// ToString returns a human-readable representation of any Value,
+// regardless of which backend produced it.
+func ToString(v Value) string {
 	switch {
-	case v.FloatValue != nil:
-		return fmt.Sprintf("%v", *v.FloatValue)
-	case v.IntValue != nil:
-		return fmt.Sprintf("%v", *v.IntValue)
-	case v.StringValue != nil:
-		return fmt.Sprintf("%q", *v.StringValue)
-	case v.BooleanValue != nil:
-		return fmt.Sprintf("%v", *v.BooleanValue)
-	case v.ListValue != nil:
-		strs := []string{}
-		for _, item := range v.ListValue.Items {
-			strs = append(strs, item.String())
+	case v.IsFloat():
+		return fmt.Sprintf("%v", v.AsFloat())
+	case v.IsInt():
+		return fmt.Sprintf("%v", v.AsInt())
+	case v.IsString():
+		return fmt.Sprintf("%q", v.AsString())
+	case v.IsBool():
+		return fmt.Sprintf("%v", v.AsBool())
+	case v.IsList():
+		l := v.AsList()
+		strs := make([]string, 0, l.Length())
+		for i := 0; i < l.Length(); i++ {
+			strs = append(strs, l.At(i).String())
 		}
 		return "[" + strings.Join(strs, ",") + "]"
-	case v.MapValue != nil:
+	case v.IsMap():
 		strs := []string{}
-		for _, i := range v.MapValue.Items {
-			strs = append(strs, fmt.Sprintf("%v=%v", i.Name, i.Value))
-		}
+		v.AsMap().Iterate(func(k string, val Value) bool {
+			strs = append(strs, fmt.Sprintf("%v=%v", k, val))
+			return true
+		})
 		return "{" + strings.Join(strs, ";") + "}"
 	default:
 		fallthrough
-	case v.Null == true:
+	case v.IsNull():
 		return "null"
 	}
 }
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "testing"
+
+func TestEqualsScalars(t *testing.T) {
+	cases := []struct {
+		name     string
+		lhs, rhs Value
+		want     bool
+	}{
+		{"equal strings", StringValue("a"), StringValue("a"), true},
+		{"different strings", StringValue("a"), StringValue("b"), false},
+		{"equal ints", IntValue(1), IntValue(1), true},
+		{"int vs equal-valued float", IntValue(1), FloatValue(1.0), true},
+		{"int vs different float", IntValue(1), FloatValue(1.5), false},
+		{"string vs int never equal", StringValue("1"), IntValue(1), false},
+		{"bool vs bool equal", BooleanValue(true), BooleanValue(true), true},
+		{"bool vs bool different", BooleanValue(true), BooleanValue(false), false},
+		{"null vs null", NullValue(), NullValue(), true},
+		{"null vs string", NullValue(), StringValue(""), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Equals(c.lhs, c.rhs); got != c.want {
+				t.Errorf("Equals(%v, %v) = %v, want %v", c.lhs, c.rhs, got, c.want)
+			}
+			if got := Equals(c.rhs, c.lhs); got != c.want {
+				t.Errorf("Equals is not symmetric for %v, %v", c.lhs, c.rhs)
+			}
+		})
+	}
+}
+
+func TestEqualsImpliesEqualHash(t *testing.T) {
+	pairs := [][2]Value{
+		{StringValue("a"), StringValue("a")},
+		{IntValue(1), FloatValue(1.0)},
+		{BooleanValue(true), BooleanValue(true)},
+		{NullValue(), NullValue()},
+	}
+	for _, p := range pairs {
+		if !Equals(p[0], p[1]) {
+			t.Fatalf("test setup bug: %v and %v are not Equals", p[0], p[1])
+		}
+		if Hash(p[0]) != Hash(p[1]) {
+			t.Errorf("Hash(%v) = %d != Hash(%v) = %d, but they are Equals", p[0], Hash(p[0]), p[1], Hash(p[1]))
+		}
+	}
+}
+
+func TestHashDistinguishesTypes(t *testing.T) {
+	// An empty map and an empty list must not collide, nor either with
+	// null, even though none of them carry any scalar content.
+	emptyMap := NewMapValue()
+	emptyList := NewListValue()
+	null := NullValue()
+
+	hashes := map[uint64]string{}
+	for name, v := range map[string]Value{"map": emptyMap, "list": emptyList, "null": null} {
+		h := Hash(v)
+		if other, ok := hashes[h]; ok {
+			t.Errorf("Hash(%s) collides with Hash(%s)", name, other)
+		}
+		hashes[h] = name
+	}
+}
+
+func TestListEqualsIsOrderSensitive(t *testing.T) {
+	a := NewListValue().AsList().(*structList)
+	a.Items = append(a.Items, StringValue("x"), StringValue("y"))
+
+	b := NewListValue().AsList().(*structList)
+	b.Items = append(b.Items, StringValue("y"), StringValue("x"))
+
+	if Equals(&structValue{ListValue: a}, &structValue{ListValue: b}) {
+		t.Fatal("listEquals treated differently-ordered lists as equal")
+	}
+
+	c := NewListValue().AsList().(*structList)
+	c.Items = append(c.Items, StringValue("x"), StringValue("y"))
+	if !Equals(&structValue{ListValue: a}, &structValue{ListValue: c}) {
+		t.Fatal("listEquals treated identically-ordered, equal lists as unequal")
+	}
+}
+
+func TestMapEqualsIsOrderInsensitive(t *testing.T) {
+	lhs := NewMapValue().AsMap()
+	lhs.Set("a", StringValue("1"))
+	lhs.Set("b", StringValue("2"))
+
+	rhs := NewMapValue().AsMap()
+	rhs.Set("b", StringValue("2"))
+	rhs.Set("a", StringValue("1"))
+
+	lv := &structValue{MapValue: lhs.(*structMap)}
+	rv := &structValue{MapValue: rhs.(*structMap)}
+	if !Equals(lv, rv) {
+		t.Fatal("mapEquals treated differently-ordered but equal maps as unequal")
+	}
+	if Hash(lv) != Hash(rv) {
+		t.Fatal("Hash differed for differently-ordered but equal maps")
+	}
+}
+
+func TestEqualsAcrossBackends(t *testing.T) {
+	type s struct {
+		Name string `json:"name"`
+	}
+	reflectVal := NewValueReflect(&s{Name: "a"})
+	unstructuredVal := NewValueUnstructured(map[string]interface{}{"name": "a"})
+	structVal := NewMapValue()
+	structVal.AsMap().Set("name", StringValue("a"))
+
+	vals := []Value{reflectVal, unstructuredVal, structVal}
+	for i := range vals {
+		for j := range vals {
+			if !Equals(vals[i], vals[j]) {
+				t.Errorf("Equals(vals[%d], vals[%d]) = false, want true (same content, different backends)", i, j)
+			}
+			if Hash(vals[i]) != Hash(vals[j]) {
+				t.Errorf("Hash(vals[%d]) != Hash(vals[%d]) for equal values from different backends", i, j)
+			}
+		}
+	}
+}
+
+func TestLessOrdersAcrossTypes(t *testing.T) {
+	// Less must provide a consistent total order: for any a, b exactly one
+	// of a<b, b<a, or a==b(neither) holds.
+	vals := []Value{NullValue(), BooleanValue(false), BooleanValue(true), IntValue(1), StringValue("a"), NewListValue(), NewMapValue()}
+	for i := range vals {
+		for j := range vals {
+			lt := Less(vals[i], vals[j])
+			gt := Less(vals[j], vals[i])
+			if lt && gt {
+				t.Fatalf("Less(%v, %v) and Less(%v, %v) both true", vals[i], vals[j], vals[j], vals[i])
+			}
+			if i == j && (lt || gt) {
+				t.Fatalf("Less(v, v) should be false, got lt=%v gt=%v for %v", lt, gt, vals[i])
+			}
+		}
+	}
+}
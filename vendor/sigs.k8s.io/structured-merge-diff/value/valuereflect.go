@@ -0,0 +1,341 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"sigs.k8s.io/structured-merge-diff/value/internal/jsontagutil"
+)
+
+// NewValueReflect creates a Value that reads obj--an arbitrary Go struct,
+// map, slice, or scalar--through reflection. No copy of obj is made, and no
+// parallel tree of Values is allocated up front: Map/List views are
+// produced lazily as callers walk the result, which matters when obj is a
+// large typed API object and only a small part of it is ever visited.
+func NewValueReflect(obj interface{}) Value {
+	return wrapReflect(reflect.ValueOf(obj))
+}
+
+func wrapReflect(rv reflect.Value) Value {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return NullValue()
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return &reflectValue{Value: rv}
+	case reflect.String:
+		return StringValue(rv.String())
+	case reflect.Bool:
+		return BooleanValue(rv.Bool())
+	case reflect.Float32, reflect.Float64:
+		return FloatValue(rv.Float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntValue(int(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IntValue(int(rv.Uint()))
+	case reflect.Invalid:
+		return NullValue()
+	default:
+		panic(fmt.Sprintf("value: NewValueReflect: unsupported kind %v", rv.Kind()))
+	}
+}
+
+// reflectValue is a Value--and, depending on rv's kind, also a Map or a
+// List--backed directly by a reflect.Value.
+type reflectValue struct {
+	Value reflect.Value
+}
+
+func (r *reflectValue) IsMap() bool {
+	k := r.Value.Kind()
+	return k == reflect.Struct || k == reflect.Map
+}
+
+func (r *reflectValue) IsList() bool {
+	k := r.Value.Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+func (r *reflectValue) IsFloat() bool  { return false }
+func (r *reflectValue) IsInt() bool    { return false }
+func (r *reflectValue) IsString() bool { return false }
+func (r *reflectValue) IsBool() bool   { return false }
+func (r *reflectValue) IsNull() bool   { return false }
+
+func (r *reflectValue) AsMap() Map {
+	if !r.IsMap() {
+		panic(fmt.Sprintf("value: AsMap called on kind %v", r.Value.Kind()))
+	}
+	return r
+}
+
+func (r *reflectValue) AsList() List {
+	if !r.IsList() {
+		panic(fmt.Sprintf("value: AsList called on kind %v", r.Value.Kind()))
+	}
+	return r
+}
+
+func (r *reflectValue) AsFloat() float64 { panic("value: AsFloat called on a Map/List") }
+func (r *reflectValue) AsInt() int64     { panic("value: AsInt called on a Map/List") }
+func (r *reflectValue) AsString() string { panic("value: AsString called on a Map/List") }
+func (r *reflectValue) AsBool() bool     { panic("value: AsBool called on a Map/List") }
+
+func (r *reflectValue) Less(rhs Value) bool   { return Less(r, rhs) }
+func (r *reflectValue) Equals(rhs Value) bool { return Equals(r, rhs) }
+func (r *reflectValue) Hash() uint64          { return Hash(r) }
+func (r *reflectValue) String() string        { return ToString(r) }
+
+// Map methods. Only valid when r.Value is a struct or a map.
+
+func (r *reflectValue) Length() int {
+	switch r.Value.Kind() {
+	case reflect.Struct:
+		n := 0
+		for _, f := range cachedFields(r.Value.Type()) {
+			if _, ok := fieldValue(r.Value, f); ok {
+				n++
+			}
+		}
+		return n
+	case reflect.Map:
+		return r.Value.Len()
+	case reflect.Slice, reflect.Array:
+		return r.Value.Len()
+	}
+	panic(fmt.Sprintf("value: Length called on kind %v", r.Value.Kind()))
+}
+
+func (r *reflectValue) Get(key string) (Value, bool) {
+	switch r.Value.Kind() {
+	case reflect.Struct:
+		for _, f := range cachedFields(r.Value.Type()) {
+			if f.jsonName != key {
+				continue
+			}
+			fv, ok := fieldValue(r.Value, f)
+			if !ok {
+				return nil, false
+			}
+			return wrapReflect(fv), true
+		}
+		return nil, false
+	case reflect.Map:
+		mv := r.Value.MapIndex(reflect.ValueOf(key))
+		if !mv.IsValid() {
+			return nil, false
+		}
+		return wrapReflect(mv), true
+	}
+	panic(fmt.Sprintf("value: Get called on kind %v", r.Value.Kind()))
+}
+
+func (r *reflectValue) Set(key string, val Value) {
+	switch r.Value.Kind() {
+	case reflect.Struct:
+		for _, f := range cachedFields(r.Value.Type()) {
+			if f.jsonName != key {
+				continue
+			}
+			fv, ok := fieldValueForSet(r.Value, f)
+			if !ok {
+				panic(fmt.Sprintf("value: cannot set field %q: reached through a nil embedded pointer that isn't addressable", key))
+			}
+			if !fv.CanSet() {
+				panic(fmt.Sprintf("value: cannot set field %q of non-addressable struct", key))
+			}
+			if val.IsNull() {
+				fv.Set(reflect.Zero(fv.Type()))
+			} else {
+				fv.Set(reflect.ValueOf(ToUnstructured(val)).Convert(fv.Type()))
+			}
+			return
+		}
+		panic(fmt.Sprintf("value: no field named %q in %v", key, r.Value.Type()))
+	case reflect.Map:
+		if !r.Value.CanSet() && r.Value.IsNil() {
+			panic("value: cannot set key on nil map")
+		}
+		elemType := r.Value.Type().Elem()
+		if val.IsNull() {
+			r.Value.SetMapIndex(reflect.ValueOf(key), reflect.Zero(elemType))
+		} else {
+			r.Value.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(ToUnstructured(val)).Convert(elemType))
+		}
+		return
+	}
+	panic(fmt.Sprintf("value: Set called on kind %v", r.Value.Kind()))
+}
+
+func (r *reflectValue) Delete(key string) {
+	switch r.Value.Kind() {
+	case reflect.Map:
+		r.Value.SetMapIndex(reflect.ValueOf(key), reflect.Value{})
+		return
+	}
+	panic(fmt.Sprintf("value: Delete not supported on kind %v", r.Value.Kind()))
+}
+
+func (r *reflectValue) Iterate(fn func(key string, val Value) bool) bool {
+	switch r.Value.Kind() {
+	case reflect.Struct:
+		for _, f := range cachedFields(r.Value.Type()) {
+			fv, ok := fieldValue(r.Value, f)
+			if !ok {
+				continue
+			}
+			if !fn(f.jsonName, wrapReflect(fv)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		iter := r.Value.MapRange()
+		for iter.Next() {
+			if !fn(iter.Key().String(), wrapReflect(iter.Value())) {
+				return false
+			}
+		}
+		return true
+	}
+	panic(fmt.Sprintf("value: Iterate called on kind %v", r.Value.Kind()))
+}
+
+// List methods. Only valid when r.Value is a slice or array.
+
+func (r *reflectValue) At(i int) Value {
+	return wrapReflect(r.Value.Index(i))
+}
+
+// fieldCacheEntry describes one JSON-visible field of a struct type: the
+// key it is addressed by, the (possibly multi-level, for inlined fields)
+// index path to reach it, and whether its tag carried "omitempty".
+type fieldCacheEntry struct {
+	jsonName  string
+	index     []int
+	omitEmpty bool
+}
+
+// fieldValue returns the field f addresses within v, and whether it is
+// present. It is absent if f.index indirects through a nil embedded
+// pointer along the way (plain reflect.Value.FieldByIndex would panic in
+// that case; encoding/json instead treats the fields behind it as
+// missing), or if f is tagged omitempty and holds its type's zero value,
+// mirroring what encoding/json would have omitted on marshal.
+func fieldValue(v reflect.Value, f fieldCacheEntry) (reflect.Value, bool) {
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	if f.omitEmpty && isEmptyValue(v) {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// fieldValueForSet is like fieldValue, but allocates nil embedded pointers
+// along the path instead of treating them as absent, since Set implies the
+// caller wants to write through them. It does not apply omitempty, since
+// that only affects whether an already-set field is considered present.
+func fieldValueForSet(v reflect.Value, f fieldCacheEntry) (reflect.Value, bool) {
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue mirrors encoding/json's definition of "empty" for the
+// purpose of the omitempty tag option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+var fieldCache sync.Map // map[reflect.Type][]fieldCacheEntry
+
+// cachedFields returns the JSON-visible fields of t, computing and caching
+// them on first use so that repeated Map operations over many instances of
+// the same type don't re-parse struct tags every time.
+func cachedFields(t reflect.Type) []fieldCacheEntry {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]fieldCacheEntry)
+	}
+	fields := computeFields(t, nil)
+	actual, _ := fieldCache.LoadOrStore(t, fields)
+	return actual.([]fieldCacheEntry)
+}
+
+func computeFields(t reflect.Type, index []int) []fieldCacheEntry {
+	var out []fieldCacheEntry
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := jsontagutil.LookupJSON(f)
+		if tag.Omit {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if tag.Inline || (f.Anonymous && tag.Name == "") {
+			if ft.Kind() == reflect.Struct {
+				out = append(out, computeFields(ft, fieldIndex)...)
+				continue
+			}
+		}
+		name := tag.Name
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, fieldCacheEntry{jsonName: name, index: fieldIndex, omitEmpty: tag.OmitEmpty})
+	}
+	return out
+}
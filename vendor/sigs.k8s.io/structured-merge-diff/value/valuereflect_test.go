@@ -0,0 +1,111 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "testing"
+
+type ReflectInner struct {
+	Value string `json:"value"`
+}
+
+type reflectOuter struct {
+	*ReflectInner `json:",inline"`
+	Name          string `json:"name"`
+	Skip          string `json:"-"`
+	Empty         string `json:"empty,omitempty"`
+	unexported    string
+}
+
+func TestReflectNilEmbeddedPointer(t *testing.T) {
+	o := &reflectOuter{Name: "n"}
+	m := NewValueReflect(o).AsMap()
+
+	if _, ok := m.Get("value"); ok {
+		t.Fatal("expected field behind nil embedded pointer to be absent")
+	}
+	if got := m.Length(); got != 1 {
+		t.Fatalf("Length() = %d, want 1 (only \"name\" is present)", got)
+	}
+
+	seen := map[string]bool{}
+	m.Iterate(func(k string, _ Value) bool {
+		seen[k] = true
+		return true
+	})
+	if seen["value"] {
+		t.Fatal("Iterate visited a field behind a nil embedded pointer")
+	}
+	if !seen["name"] {
+		t.Fatal("Iterate did not visit \"name\"")
+	}
+}
+
+func TestReflectSetAllocatesNilEmbeddedPointer(t *testing.T) {
+	o := &reflectOuter{Name: "n"}
+	m := NewValueReflect(o).AsMap()
+
+	m.Set("value", StringValue("hi"))
+	if o.ReflectInner == nil || o.ReflectInner.Value != "hi" {
+		t.Fatalf("Set did not allocate the nil embedded pointer: %+v", o)
+	}
+}
+
+func TestReflectOmitEmpty(t *testing.T) {
+	o := &reflectOuter{ReflectInner: &ReflectInner{}, Name: "n"}
+	m := NewValueReflect(o).AsMap()
+
+	if _, ok := m.Get("empty"); ok {
+		t.Fatal("expected omitempty zero-valued field to be absent")
+	}
+	o.Empty = "not empty anymore"
+	if v, ok := m.Get("empty"); !ok || v.AsString() != "not empty anymore" {
+		t.Fatal("expected non-zero omitempty field to be present")
+	}
+}
+
+func TestReflectSkipsUnexportedAndDashTagged(t *testing.T) {
+	o := &reflectOuter{ReflectInner: &ReflectInner{}, Name: "n", Skip: "s"}
+	m := NewValueReflect(o).AsMap()
+
+	if _, ok := m.Get("Skip"); ok {
+		t.Fatal("field tagged json:\"-\" should not be visible")
+	}
+	if _, ok := m.Get("unexported"); ok {
+		t.Fatal("unexported field should not be visible")
+	}
+}
+
+func TestReflectSetNullZeroesField(t *testing.T) {
+	o := &reflectOuter{ReflectInner: &ReflectInner{}, Name: "n"}
+	m := NewValueReflect(o).AsMap()
+
+	m.Set("name", NullValue())
+	if o.Name != "" {
+		t.Fatalf("Set(NullValue()) did not zero the field, got %q", o.Name)
+	}
+}
+
+func TestReflectSetNullOnMap(t *testing.T) {
+	s := "x"
+	mp := map[string]*string{"k": &s}
+	m := NewValueReflect(mp).AsMap()
+
+	m.Set("k", NullValue())
+	if mp["k"] != nil {
+		t.Fatal("Set(NullValue()) did not clear the map entry")
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "fmt"
+
+// NewValueUnstructured creates a Value that wraps obj in place. obj must be
+// a nil, bool, int64/float64, string, map[string]interface{}, or
+// []interface{}, i.e. the shapes that encoding/json produces when decoding
+// into interface{}, or an int or int32. Other numeric kinds (e.g.
+// float32 or uint64) are not recognized: every Is* method of the resulting
+// Value returns false for them. No copy is made,
+// and no parallel Value tree is allocated: Map/List views index back into
+// obj on demand.
+func NewValueUnstructured(obj interface{}) Value {
+	return unstructuredValue{obj}
+}
+
+// unstructuredValue wraps a single interface{} produced by, e.g.,
+// json.Unmarshal(data, &interface{}{}).
+type unstructuredValue struct {
+	Value interface{}
+}
+
+func (v unstructuredValue) IsMap() bool {
+	_, ok := v.Value.(map[string]interface{})
+	return ok
+}
+
+func (v unstructuredValue) IsList() bool {
+	_, ok := v.Value.([]interface{})
+	return ok
+}
+
+func (v unstructuredValue) IsFloat() bool {
+	_, ok := v.Value.(float64)
+	return ok
+}
+
+func (v unstructuredValue) IsInt() bool {
+	switch v.Value.(type) {
+	case int, int32, int64:
+		return true
+	}
+	return false
+}
+
+func (v unstructuredValue) IsString() bool {
+	_, ok := v.Value.(string)
+	return ok
+}
+
+func (v unstructuredValue) IsBool() bool {
+	_, ok := v.Value.(bool)
+	return ok
+}
+
+func (v unstructuredValue) IsNull() bool { return v.Value == nil }
+
+func (v unstructuredValue) AsMap() Map {
+	m, ok := v.Value.(map[string]interface{})
+	if !ok {
+		panic(fmt.Sprintf("value: AsMap called on %T", v.Value))
+	}
+	return unstructuredMap(m)
+}
+
+func (v unstructuredValue) AsList() List {
+	l, ok := v.Value.([]interface{})
+	if !ok {
+		panic(fmt.Sprintf("value: AsList called on %T", v.Value))
+	}
+	return unstructuredList(l)
+}
+
+func (v unstructuredValue) AsFloat() float64 {
+	switch n := v.Value.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	panic(fmt.Sprintf("value: AsFloat called on %T", v.Value))
+}
+
+func (v unstructuredValue) AsInt() int64 {
+	switch n := v.Value.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	}
+	panic(fmt.Sprintf("value: AsInt called on %T", v.Value))
+}
+
+func (v unstructuredValue) AsString() string {
+	return v.Value.(string)
+}
+
+func (v unstructuredValue) AsBool() bool {
+	return v.Value.(bool)
+}
+
+func (v unstructuredValue) Less(rhs Value) bool   { return Less(v, rhs) }
+func (v unstructuredValue) Equals(rhs Value) bool { return Equals(v, rhs) }
+func (v unstructuredValue) Hash() uint64          { return Hash(v) }
+func (v unstructuredValue) String() string        { return ToString(v) }
+
+// unstructuredMap is a Map view over a map[string]interface{}, with no
+// copying: Get/Set/Delete/Iterate operate directly on the underlying Go map.
+type unstructuredMap map[string]interface{}
+
+func (m unstructuredMap) Length() int { return len(m) }
+
+func (m unstructuredMap) Get(key string) (Value, bool) {
+	v, ok := m[key]
+	if !ok {
+		return nil, false
+	}
+	return NewValueUnstructured(v), true
+}
+
+func (m unstructuredMap) Set(key string, val Value) {
+	m[key] = ToUnstructured(val)
+}
+
+func (m unstructuredMap) Delete(key string) {
+	delete(m, key)
+}
+
+func (m unstructuredMap) Iterate(fn func(key string, val Value) bool) bool {
+	for k, v := range m {
+		if !fn(k, NewValueUnstructured(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// unstructuredList is a List view over a []interface{}, with no copying.
+type unstructuredList []interface{}
+
+func (l unstructuredList) Length() int { return len(l) }
+
+func (l unstructuredList) At(i int) Value {
+	return NewValueUnstructured(l[i])
+}